@@ -6,19 +6,23 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"github.com/lib/pq"
 	"time"
+
+	sq "github.com/Masterminds/squirrel"
+	"github.com/lib/pq"
 )
 
 type Books struct {
-	ID        int64     `json:"id"`
-	CreatedAt time.Time `json:"-"`
-	Title     string    `json:"title"`
-	Year      int32     `json:"year,omitempty"`
-	Runtime   Runtime   `json:"runtime,omitempty"`
-	Genres    []string  `json:"genres,omitempty"`
-	Version   int32     `json:"version"`
-	Price     int64     `json:"price"`
+	ID            int64        `json:"id"`
+	CreatedAt     time.Time    `json:"-"`
+	Title         string       `json:"title"`
+	Year          int32        `json:"year,omitempty"`
+	Runtime       Runtime      `json:"runtime,omitempty"`
+	Genres        []string     `json:"genres,omitempty"`
+	Version       int32        `json:"version"`
+	Price         int64        `json:"price"`
+	StockQuantity int32        `json:"stock_quantity"`
+	DeletedAt     sql.NullTime `json:"-"`
 }
 
 func ValidateBooks(v *validator.Validator, books *Books) {
@@ -36,22 +40,109 @@ func ValidateBooks(v *validator.Validator, books *Books) {
 	v.Check(books.Price > 10, "price", "price must be higher than 10 bucks")
 }
 
+// BookFilter describes optional criteria used to narrow down
+// BookModel.GetAll. A field left at its zero value is not applied.
+type BookFilter struct {
+	MinPrice      int64
+	MaxPrice      int64
+	YearFrom      int32
+	YearTo        int32
+	GenresAny     []string
+	GenresAll     []string
+	ExcludeGenres []string
+	TitleSearch   string
+	PublisherID   int64
+}
+
+// filterMappings maps each BookFilter field to a function building its
+// squirrel.Sqlizer clause, one entry per supported filter. Adding a new
+// filter means adding a map entry, not touching GetAll's query-assembly
+// logic.
+var filterMappings = map[string]func(BookFilter) sq.Sqlizer{
+	"min_price": func(f BookFilter) sq.Sqlizer {
+		if f.MinPrice == 0 {
+			return nil
+		}
+		return sq.GtOrEq{"price": f.MinPrice}
+	},
+	"max_price": func(f BookFilter) sq.Sqlizer {
+		if f.MaxPrice == 0 {
+			return nil
+		}
+		return sq.LtOrEq{"price": f.MaxPrice}
+	},
+	"year_from": func(f BookFilter) sq.Sqlizer {
+		if f.YearFrom == 0 {
+			return nil
+		}
+		return sq.GtOrEq{"year": f.YearFrom}
+	},
+	"year_to": func(f BookFilter) sq.Sqlizer {
+		if f.YearTo == 0 {
+			return nil
+		}
+		return sq.LtOrEq{"year": f.YearTo}
+	},
+	"genres_any": func(f BookFilter) sq.Sqlizer {
+		if len(f.GenresAny) == 0 {
+			return nil
+		}
+		return sq.Expr("genres && ?", pq.Array(f.GenresAny))
+	},
+	"genres_all": func(f BookFilter) sq.Sqlizer {
+		if len(f.GenresAll) == 0 {
+			return nil
+		}
+		return sq.Expr("genres @> ?", pq.Array(f.GenresAll))
+	},
+	"exclude_genres": func(f BookFilter) sq.Sqlizer {
+		if len(f.ExcludeGenres) == 0 {
+			return nil
+		}
+		return sq.Expr("NOT (genres && ?)", pq.Array(f.ExcludeGenres))
+	},
+	"title_search": func(f BookFilter) sq.Sqlizer {
+		if f.TitleSearch == "" {
+			return nil
+		}
+		return sq.Expr("title_search @@ plainto_tsquery('simple', ?)", f.TitleSearch)
+	},
+	"publisher_id": func(f BookFilter) sq.Sqlizer {
+		if f.PublisherID == 0 {
+			return nil
+		}
+		return sq.Eq{"publisher_id": f.PublisherID}
+	},
+}
+
+// clauses returns the WHERE-clause predicates implied by f, skipping
+// every filter field that wasn't set.
+func (f BookFilter) clauses() []sq.Sqlizer {
+	var clauses []sq.Sqlizer
+	for _, build := range filterMappings {
+		if clause := build(f); clause != nil {
+			clauses = append(clauses, clause)
+		}
+	}
+	return clauses
+}
+
 type BookModel struct {
 	DB *sql.DB
 }
 
 func (m BookModel) Insert(book *Books) error {
 	query := `
-INSERT INTO books (title, year, runtime, genres, price)
-VALUES ($1, $2, $3, $4, $5)
+INSERT INTO books (title, year, runtime, genres, price, stock_quantity)
+VALUES ($1, $2, $3, $4, $5, $6)
 RETURNING id, created_at, version`
-	args := []interface{}{book.Title, book.Year, book.Runtime, pq.Array(book.Genres), book.Price}
+	args := []interface{}{book.Title, book.Year, book.Runtime, pq.Array(book.Genres), book.Price, book.StockQuantity}
 	// Create a context with a 3-second timeout.
 	// Create a context with a 3-second timeout.
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
 	// Use QueryRowContext() and pass the context as the first argument.
-	return m.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.CreatedAt, &book.Version, &book.Price)
+	return m.DB.QueryRowContext(ctx, query, args...).Scan(&book.ID, &book.CreatedAt, &book.Version)
 }
 func (m BookModel) Get(id int64) (*Books, error) {
 	if id < 1 {
@@ -59,9 +150,9 @@ func (m BookModel) Get(id int64) (*Books, error) {
 	}
 	// Remove the pg_sleep(10) clause.
 	query := `
-SELECT id, created_at, title, year, runtime, genres, version, price
+SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity
 FROM books
-WHERE id = $1`
+WHERE id = $1 AND deleted_at IS NULL`
 	var book Books
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -75,6 +166,7 @@ WHERE id = $1`
 		pq.Array(&book.Genres),
 		&book.Version,
 		&book.Price,
+		&book.StockQuantity,
 	)
 	if err != nil {
 		switch {
@@ -89,8 +181,8 @@ WHERE id = $1`
 func (m BookModel) Update(book *Books) error {
 	query := `
 UPDATE books
-SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1, price = $5
-WHERE id = $6 AND version = $7
+SET title = $1, year = $2, runtime = $3, genres = $4, version = version + 1, price = $5, stock_quantity = $6
+WHERE id = $7 AND version = $8 AND deleted_at IS NULL
 RETURNING version`
 	args := []interface{}{
 		book.Title,
@@ -98,6 +190,7 @@ RETURNING version`
 		book.Runtime,
 		pq.Array(book.Genres),
 		book.Price,
+		book.StockQuantity,
 		book.ID,
 		book.Version,
 	}
@@ -116,13 +209,73 @@ RETURNING version`
 	}
 	return nil
 }
+
+// GetWithRevision fetches book id and returns its current version
+// alongside it, so an HTTP handler can set it as an ETag without
+// reaching back into the returned struct.
+func (m BookModel) GetWithRevision(id int64) (*Books, int32, error) {
+	book, err := m.Get(id)
+	if err != nil {
+		return nil, 0, err
+	}
+	return book, book.Version, nil
+}
+
+// Patch loads book id, checks it against ifMatch (an If-Match revision
+// supplied by the client; 0 means "don't check"), applies mutate, and
+// saves it. If the row changed between the load and the save, it
+// reloads once and retries the mutation — but only if the reloaded
+// version still satisfies ifMatch; otherwise it gives up with
+// ErrEditConflict rather than silently applying the mutation on top of
+// someone else's write.
+func (m BookModel) Patch(id int64, ifMatch int32, mutate func(*Books) error) (*Books, error) {
+	book, err := m.Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ifMatch != 0 && book.Version != ifMatch {
+		return nil, ErrEditConflict
+	}
+
+	if err := mutate(book); err != nil {
+		return nil, err
+	}
+
+	err = m.Update(book)
+	if errors.Is(err, ErrEditConflict) {
+		book, err = m.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if ifMatch != 0 && book.Version != ifMatch {
+			return nil, ErrEditConflict
+		}
+		if err := mutate(book); err != nil {
+			return nil, err
+		}
+		if err := m.Update(book); err != nil {
+			return nil, err
+		}
+		return book, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return book, nil
+}
+
+// Delete soft-deletes book id by stamping deleted_at instead of
+// removing the row, so order_items that reference it stay intact.
 func (m BookModel) Delete(id int64) error {
 	if id < 1 {
 		return ErrRecordNotFound
 	}
 	query := `
-DELETE FROM books
-WHERE id = $1`
+UPDATE books
+SET deleted_at = NOW()
+WHERE id = $1 AND deleted_at IS NULL`
 
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
@@ -141,18 +294,72 @@ WHERE id = $1`
 	return nil
 }
 
-func (m BookModel) GetAll(title string, genres []string, filters Filters) ([]*Books, Metadata, error) {
+// Restore clears deleted_at on a previously soft-deleted book, making
+// it visible to Get, Update and GetAll again.
+func (m BookModel) Restore(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+	query := `
+UPDATE books
+SET deleted_at = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	result, err := m.DB.ExecContext(ctx, query, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+	return nil
+}
+
+// GetAll returns non-deleted books matching filter, paginated according
+// to filters.
+func (m BookModel) GetAll(filter BookFilter, filters Filters) ([]*Books, Metadata, error) {
+	return m.getAll(filter, filters, true)
+}
+
+// GetAllIncludingDeleted is the admin-facing counterpart of GetAll: it
+// returns every book matching filter, soft-deleted or not.
+func (m BookModel) GetAllIncludingDeleted(filter BookFilter, filters Filters) ([]*Books, Metadata, error) {
+	return m.getAll(filter, filters, false)
+}
+
+func (m BookModel) getAll(filter BookFilter, filters Filters, excludeDeleted bool) ([]*Books, Metadata, error) {
+
+	builder := sq.Select(
+		"count(*) OVER()", "id", "created_at", "title", "year", "runtime", "genres", "version", "price", "stock_quantity", "deleted_at",
+	).
+		From("books").
+		PlaceholderFormat(sq.Dollar).
+		OrderBy(fmt.Sprintf("%s %s", filters.sortColumn(), filters.sortDirection()), "id ASC").
+		Limit(uint64(filters.limit())).
+		Offset(uint64(filters.offset()))
+
+	if excludeDeleted {
+		builder = builder.Where(sq.Eq{"deleted_at": nil})
+	}
+
+	for _, clause := range filter.clauses() {
+		builder = builder.Where(clause)
+	}
+
+	query, args, err := builder.ToSql()
+	if err != nil {
+		return nil, Metadata{}, err
+	}
 
-	query := fmt.Sprintf(`
-SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, price
-FROM books
-WHERE (to_tsvector('simple', title) @@ plainto_tsquery('simple', $1) OR $1 = '')
-AND (genres @> $2 OR $2 = '{}')
-ORDER BY %s %s, id ASC
-LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	args := []interface{}{title, pq.Array(genres), filters.limit(), filters.offset()}
 	rows, err := m.DB.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, Metadata{}, err // Update this to return an empty Metadata struct.
@@ -172,6 +379,8 @@ LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 			pq.Array(&book.Genres),
 			&book.Version,
 			&book.Price,
+			&book.StockQuantity,
+			&book.DeletedAt,
 		)
 		if err != nil {
 			return nil, Metadata{}, err // Update this to return an empty Metadata struct.
@@ -186,3 +395,75 @@ LIMIT $3 OFFSET $4`, filters.sortColumn(), filters.sortDirection())
 
 	return books, metadata, nil
 }
+
+// SearchHit is a Books row ranked by full-text relevance, with the
+// matching title snippet marked up by ts_headline.
+type SearchHit struct {
+	Books
+	Highlight string `json:"highlight"`
+}
+
+// Search ranks non-deleted books against q using ts_rank_cd over the
+// generated title_search column and returns ts_headline snippets
+// alongside them. When q is empty it falls back to the unranked
+// GetAll path.
+func (m BookModel) Search(q string, filters Filters) ([]*SearchHit, Metadata, error) {
+	if q == "" {
+		books, metadata, err := m.GetAll(BookFilter{}, filters)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		hits := make([]*SearchHit, len(books))
+		for i, book := range books {
+			hits[i] = &SearchHit{Books: *book}
+		}
+		return hits, metadata, nil
+	}
+
+	query := `
+SELECT count(*) OVER(), id, created_at, title, year, runtime, genres, version, price, stock_quantity,
+       ts_headline('simple', title, plainto_tsquery('simple', $1)) AS highlight
+FROM books
+WHERE deleted_at IS NULL AND title_search @@ plainto_tsquery('simple', $1)
+ORDER BY ts_rank_cd(title_search, plainto_tsquery('simple', $1)) DESC, id ASC
+LIMIT $2 OFFSET $3`
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, query, q, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	hits := []*SearchHit{}
+	for rows.Next() {
+		var hit SearchHit
+		err := rows.Scan(
+			&totalRecords,
+			&hit.ID,
+			&hit.CreatedAt,
+			&hit.Title,
+			&hit.Year,
+			&hit.Runtime,
+			pq.Array(&hit.Genres),
+			&hit.Version,
+			&hit.Price,
+			&hit.StockQuantity,
+			&hit.Highlight,
+		)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		hits = append(hits, &hit)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return hits, metadata, nil
+}
@@ -0,0 +1,117 @@
+package data
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func newBookRow(id int64, version int32) *sqlmock.Rows {
+	return sqlmock.NewRows([]string{
+		"id", "created_at", "title", "year", "runtime", "genres", "version", "price", "stock_quantity",
+	}).AddRow(id, time.Now(), "Some Title", int32(2000), Runtime(120), "{fiction}", version, int64(1500), int32(5))
+}
+
+func TestPatchRetriesOnConflictWithoutIfMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := BookModel{DB: db}
+
+	mock.ExpectQuery(`SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity`).
+		WithArgs(int64(1)).
+		WillReturnRows(newBookRow(1, 3))
+	mock.ExpectQuery(`UPDATE books`).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity`).
+		WithArgs(int64(1)).
+		WillReturnRows(newBookRow(1, 4))
+	mock.ExpectQuery(`UPDATE books`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(int32(5)))
+
+	book, err := m.Patch(1, 0, func(b *Books) error {
+		b.Price += 100
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Patch() error = %v, want nil", err)
+	}
+	if book.Version != 5 {
+		t.Errorf("Patch() version = %d, want 5", book.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+// TestPatchFailsIfMatchStaleAfterReload guards against regressing to the
+// behavior fixed in bc10b14: a conflict reload must re-check ifMatch,
+// not silently re-apply mutate on top of someone else's write.
+func TestPatchFailsIfMatchStaleAfterReload(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := BookModel{DB: db}
+
+	mock.ExpectQuery(`SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity`).
+		WithArgs(int64(1)).
+		WillReturnRows(newBookRow(1, 3))
+	mock.ExpectQuery(`UPDATE books`).
+		WillReturnError(sql.ErrNoRows)
+	mock.ExpectQuery(`SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity`).
+		WithArgs(int64(1)).
+		WillReturnRows(newBookRow(1, 4))
+
+	_, err = m.Patch(1, 3, func(b *Books) error {
+		b.Price += 100
+		return nil
+	})
+	if !errors.Is(err, ErrEditConflict) {
+		t.Fatalf("Patch() error = %v, want ErrEditConflict", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPatchSucceedsWithMatchingIfMatch(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := BookModel{DB: db}
+
+	mock.ExpectQuery(`SELECT id, created_at, title, year, runtime, genres, version, price, stock_quantity`).
+		WithArgs(int64(1)).
+		WillReturnRows(newBookRow(1, 3))
+	mock.ExpectQuery(`UPDATE books`).
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(int32(4)))
+
+	book, err := m.Patch(1, 3, func(b *Books) error {
+		b.Price += 100
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Patch() error = %v, want nil", err)
+	}
+	if book.Version != 4 {
+		t.Errorf("Patch() version = %d, want 4", book.Version)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
@@ -0,0 +1,15 @@
+package data
+
+import (
+	"database/sql"
+
+	"bookstore/internal/data/migrations"
+)
+
+// Migrate applies all outstanding schema migrations to db. The server
+// calls this once during startup, before the connection pool is handed
+// off to the rest of the application, so that dev and prod databases
+// never drift from the schema the code expects.
+func Migrate(db *sql.DB) error {
+	return migrations.Up(db)
+}
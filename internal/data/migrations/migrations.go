@@ -0,0 +1,30 @@
+// Package migrations embeds the bookstore's versioned SQL schema
+// migrations and applies them with goose.
+package migrations
+
+import (
+	"database/sql"
+	"embed"
+	"fmt"
+
+	"github.com/pressly/goose/v3"
+)
+
+//go:embed *.sql
+var FS embed.FS
+
+// Up applies every migration embedded in this package that has not yet
+// been recorded against db, in order.
+func Up(db *sql.DB) error {
+	goose.SetBaseFS(FS)
+
+	if err := goose.SetDialect("postgres"); err != nil {
+		return fmt.Errorf("migrations: set dialect: %w", err)
+	}
+
+	if err := goose.Up(db, "."); err != nil {
+		return fmt.Errorf("migrations: up: %w", err)
+	}
+
+	return nil
+}
@@ -15,6 +15,8 @@ type Models struct {
 	Tokens      TokenModel // Add a new Tokens field.
 	Users       UserModel
 	Permissions PermissionModel
+	Orders      OrderModel
+	Stock       StockModel
 }
 
 func NewModels(db *sql.DB) Models {
@@ -23,5 +25,7 @@ func NewModels(db *sql.DB) Models {
 		Tokens:      TokenModel{DB: db}, // Initialize a new TokenModel instance.
 		Users:       UserModel{DB: db},
 		Permissions: PermissionModel{DB: db},
+		Orders:      OrderModel{DB: db},
+		Stock:       StockModel{DB: db},
 	}
 }
@@ -0,0 +1,305 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+var (
+	ErrInsufficientStock = errors.New("insufficient stock")
+	ErrInvalidOrderItem  = errors.New("invalid order item")
+)
+
+type Order struct {
+	ID        int64       `json:"id"`
+	CreatedAt time.Time   `json:"created_at"`
+	UserID    int64       `json:"user_id"`
+	Status    string      `json:"status"`
+	Items     []OrderItem `json:"items"`
+	Version   int32       `json:"version"`
+}
+
+type OrderItem struct {
+	ID        int64 `json:"id,omitempty"`
+	BookID    int64 `json:"book_id"`
+	Quantity  int32 `json:"quantity"`
+	UnitPrice int64 `json:"unit_price,omitempty"`
+}
+
+type OrderModel struct {
+	DB *sql.DB
+}
+
+type StockModel struct {
+	DB *sql.DB
+}
+
+// Quantity returns the current stock_quantity for book id.
+func (m StockModel) Quantity(bookID int64) (int32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	var quantity int32
+	err := m.DB.QueryRowContext(ctx, `
+SELECT stock_quantity
+FROM books
+WHERE id = $1 AND deleted_at IS NULL`, bookID,
+	).Scan(&quantity)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return 0, ErrRecordNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return quantity, nil
+}
+
+// mergeOrderItems sums quantities for repeated book IDs and returns the
+// result sorted by book_id, so Place always locks book rows in a
+// consistent order and can't deadlock against a concurrent checkout
+// that locks the same books in the opposite order.
+func mergeOrderItems(items []OrderItem) []OrderItem {
+	quantities := make(map[int64]int32, len(items))
+	var bookIDs []int64
+	for _, item := range items {
+		if _, ok := quantities[item.BookID]; !ok {
+			bookIDs = append(bookIDs, item.BookID)
+		}
+		quantities[item.BookID] += item.Quantity
+	}
+	sort.Slice(bookIDs, func(i, j int) bool { return bookIDs[i] < bookIDs[j] })
+
+	merged := make([]OrderItem, len(bookIDs))
+	for i, bookID := range bookIDs {
+		merged[i] = OrderItem{BookID: bookID, Quantity: quantities[bookID]}
+	}
+	return merged
+}
+
+// Place opens a transaction, locks the affected book rows, validates
+// that enough stock is available for every item, decrements it, and
+// inserts the order and its items. It returns ErrInsufficientStock if
+// any book can't cover the requested quantity.
+func (m OrderModel) Place(userID int64, items []OrderItem) (*Order, error) {
+	if len(items) == 0 {
+		return nil, ErrInvalidOrderItem
+	}
+	for _, item := range items {
+		if item.Quantity <= 0 {
+			return nil, ErrInvalidOrderItem
+		}
+	}
+	items = mergeOrderItems(items)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	order := &Order{UserID: userID, Status: "placed", Items: items}
+
+	err = tx.QueryRowContext(ctx, `
+INSERT INTO orders (user_id, status)
+VALUES ($1, $2)
+RETURNING id, created_at, version`,
+		userID, order.Status,
+	).Scan(&order.ID, &order.CreatedAt, &order.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	for i, item := range items {
+		var stockQuantity int32
+		var unitPrice int64
+
+		err := tx.QueryRowContext(ctx, `
+SELECT stock_quantity, price
+FROM books
+WHERE id = $1 AND deleted_at IS NULL
+FOR UPDATE`, item.BookID,
+		).Scan(&stockQuantity, &unitPrice)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return nil, ErrRecordNotFound
+		case err != nil:
+			return nil, err
+		}
+
+		if stockQuantity < item.Quantity {
+			return nil, ErrInsufficientStock
+		}
+
+		_, err = tx.ExecContext(ctx, `
+UPDATE books
+SET stock_quantity = stock_quantity - $1
+WHERE id = $2`, item.Quantity, item.BookID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+INSERT INTO order_items (order_id, book_id, quantity, unit_price)
+VALUES ($1, $2, $3, $4)
+RETURNING id`,
+			order.ID, item.BookID, item.Quantity, unitPrice,
+		).Scan(&items[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		items[i].UnitPrice = unitPrice
+	}
+
+	order.Items = items
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return order, nil
+}
+
+// Cancel marks order id as cancelled and restocks every item it
+// contains, all within a single transaction.
+func (m OrderModel) Cancel(id int64) error {
+	if id < 1 {
+		return ErrRecordNotFound
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tx, err := m.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, `
+SELECT book_id, quantity
+FROM order_items
+WHERE order_id = $1
+ORDER BY book_id ASC`, id)
+	if err != nil {
+		return err
+	}
+
+	var items []OrderItem
+	for rows.Next() {
+		var item OrderItem
+		if err := rows.Scan(&item.BookID, &item.Quantity); err != nil {
+			rows.Close()
+			return err
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	result, err := tx.ExecContext(ctx, `
+UPDATE orders
+SET status = 'cancelled', version = version + 1
+WHERE id = $1 AND status != 'cancelled'`, id)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrRecordNotFound
+	}
+
+	for _, item := range items {
+		_, err := tx.ExecContext(ctx, `
+UPDATE books
+SET stock_quantity = stock_quantity + $1
+WHERE id = $2`, item.Quantity, item.BookID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetForUser returns every order placed by userID, most recent first.
+func (m OrderModel) GetForUser(userID int64) ([]*Order, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	rows, err := m.DB.QueryContext(ctx, `
+SELECT id, created_at, user_id, status, version
+FROM orders
+WHERE user_id = $1
+ORDER BY created_at DESC`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []*Order{}
+	for rows.Next() {
+		var order Order
+		err := rows.Scan(&order.ID, &order.CreatedAt, &order.UserID, &order.Status, &order.Version)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return orders, nil
+}
+
+// GetAll returns every order in the system, following the same
+// Filters/Metadata pagination pattern as BookModel.GetAll.
+func (m OrderModel) GetAll(filters Filters) ([]*Order, Metadata, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	query := `
+SELECT count(*) OVER(), id, created_at, user_id, status, version
+FROM orders
+ORDER BY id ASC
+LIMIT $1 OFFSET $2`
+
+	rows, err := m.DB.QueryContext(ctx, query, filters.limit(), filters.offset())
+	if err != nil {
+		return nil, Metadata{}, err
+	}
+	defer rows.Close()
+
+	totalRecords := 0
+	orders := []*Order{}
+	for rows.Next() {
+		var order Order
+		err := rows.Scan(&totalRecords, &order.ID, &order.CreatedAt, &order.UserID, &order.Status, &order.Version)
+		if err != nil {
+			return nil, Metadata{}, err
+		}
+		orders = append(orders, &order)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, Metadata{}, err
+	}
+
+	metadata := calculateMetadata(totalRecords, filters.Page, filters.PageSize)
+
+	return orders, metadata, nil
+}
@@ -0,0 +1,144 @@
+package data
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	sqlmock "github.com/DATA-DOG/go-sqlmock"
+)
+
+func TestMergeOrderItems(t *testing.T) {
+	tests := []struct {
+		name  string
+		items []OrderItem
+		want  []OrderItem
+	}{
+		{
+			name:  "sorts distinct books by id",
+			items: []OrderItem{{BookID: 3, Quantity: 1}, {BookID: 1, Quantity: 2}},
+			want:  []OrderItem{{BookID: 1, Quantity: 2}, {BookID: 3, Quantity: 1}},
+		},
+		{
+			name:  "merges duplicate book ids",
+			items: []OrderItem{{BookID: 2, Quantity: 1}, {BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 3}},
+			want:  []OrderItem{{BookID: 1, Quantity: 2}, {BookID: 2, Quantity: 4}},
+		},
+		{
+			name:  "single item",
+			items: []OrderItem{{BookID: 5, Quantity: 1}},
+			want:  []OrderItem{{BookID: 5, Quantity: 1}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := mergeOrderItems(tt.items)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("mergeOrderItems(%v) = %v, want %v", tt.items, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPlaceRejectsInvalidItems(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := OrderModel{DB: db}
+
+	tests := []struct {
+		name  string
+		items []OrderItem
+	}{
+		{name: "no items", items: nil},
+		{name: "zero quantity", items: []OrderItem{{BookID: 1, Quantity: 0}}},
+		{name: "negative quantity", items: []OrderItem{{BookID: 1, Quantity: -1}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := m.Place(7, tt.items); !errors.Is(err, ErrInvalidOrderItem) {
+				t.Fatalf("Place() error = %v, want ErrInvalidOrderItem", err)
+			}
+		})
+	}
+
+	// None of the rejected calls should have touched the database.
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPlaceInsufficientStockRollsBack(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := OrderModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO orders`).
+		WithArgs(int64(7), "placed").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).
+			AddRow(int64(1), time.Now(), int32(1)))
+	mock.ExpectQuery(`SELECT stock_quantity, price`).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock_quantity", "price"}).
+			AddRow(int32(0), int64(1500)))
+	mock.ExpectRollback()
+
+	_, err = m.Place(7, []OrderItem{{BookID: 42, Quantity: 1}})
+	if !errors.Is(err, ErrInsufficientStock) {
+		t.Fatalf("Place() error = %v, want ErrInsufficientStock", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}
+
+func TestPlaceDecrementsStockAndCommits(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("sqlmock.New: %v", err)
+	}
+	defer db.Close()
+
+	m := OrderModel{DB: db}
+
+	mock.ExpectBegin()
+	mock.ExpectQuery(`INSERT INTO orders`).
+		WithArgs(int64(7), "placed").
+		WillReturnRows(sqlmock.NewRows([]string{"id", "created_at", "version"}).
+			AddRow(int64(1), time.Now(), int32(1)))
+	mock.ExpectQuery(`SELECT stock_quantity, price`).
+		WithArgs(int64(42)).
+		WillReturnRows(sqlmock.NewRows([]string{"stock_quantity", "price"}).
+			AddRow(int32(5), int64(1500)))
+	mock.ExpectExec(`UPDATE books`).
+		WithArgs(int32(2), int64(42)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectQuery(`INSERT INTO order_items`).
+		WithArgs(int64(1), int64(42), int32(2), int64(1500)).
+		WillReturnRows(sqlmock.NewRows([]string{"id"}).AddRow(int64(9)))
+	mock.ExpectCommit()
+
+	order, err := m.Place(7, []OrderItem{{BookID: 42, Quantity: 2}})
+	if err != nil {
+		t.Fatalf("Place() error = %v, want nil", err)
+	}
+	if order.ID != 1 || len(order.Items) != 1 || order.Items[0].UnitPrice != 1500 {
+		t.Errorf("Place() = %+v, want order 1 with one priced item", order)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("unmet expectations: %v", err)
+	}
+}